@@ -3,141 +3,94 @@ package httpvcr
 import (
 	"bytes"
 	"compress/gzip"
-	"encoding/json"
-	"fmt"
 	"io"
 	"io/ioutil"
-	"os"
-	"reflect"
+	"net/http"
+	"sync"
 )
 
-type EpisodeMatcher interface {
-	Init(episodes []Episode)
-	MatchEpisode(request *VCRRequest) (*Episode, error)
-}
-
-type DefaultEpisodeMatcher struct {
-	episodes []Episode
-}
-
-func (m *DefaultEpisodeMatcher) Init(episodes []Episode) {
-	m.episodes = episodes
-}
-
-func (m *DefaultEpisodeMatcher) MatchEpisode(request *VCRRequest) (*Episode, error) {
-	if len(m.episodes) == 0 {
-		panic("httpvcr: no more episodes!")
-	}
-
-	e := m.episodes[0]
-	expected := e.Request
-
-	if expected.Method != request.Method {
-		panicEpisodeMismatch(request, "Method", expected.Method, request.Method)
-	}
-
-	if expected.URL != request.URL {
-		panicEpisodeMismatch(request, "URL", expected.URL, request.URL)
-	}
-
-	if !reflect.DeepEqual(expected.Body, request.Body) {
-		panicEpisodeMismatch(request, "Body", string(expected.Body[:]), string(request.Body[:]))
-	}
-
-	m.episodes = m.episodes[1:]
-	return &e, nil
-}
-
 type cassette struct {
 	name           string
 	Episodes       []Episode
-	Gzip           bool
+	Store          CassetteStore
 	episodeMatcher EpisodeMatcher
+
+	// mu guards Episodes and episodeMatcher against concurrent RoundTrips,
+	// e.g. goroutines or an http.Client reusing connections during replay.
+	mu sync.Mutex
 }
 
 type Episode struct {
 	Request  *VCRRequest
 	Response *VCRResponse
+	// Error holds a recorded transport error when the original round trip
+	// failed (DNS failure, TLS error, cancelled context, ...). Response is
+	// nil when Error is set.
+	Error *RecordedError `json:",omitempty" yaml:",omitempty"`
+	// Consumed marks an episode as already matched during replay. It is
+	// runtime state, not part of the recording, so it is never persisted.
+	Consumed bool `json:"-" yaml:"-"`
 }
 
 func (c *cassette) Name() string {
 	return c.name
 }
 
-func (c *cassette) Filename() string {
-	if c.Gzip {
-		return "fixtures/vcr/" + c.name + ".json.gz"
-	} else {
-		return "fixtures/vcr/" + c.name + ".json"
+func (c *cassette) store() CassetteStore {
+	if c.Store == nil {
+		return NewJSONFileStore("")
 	}
+	return c.Store
 }
 
 func (c *cassette) Exists() bool {
-	_, err := os.Stat(c.Filename())
-	return err == nil
+	return c.store().Exists(c.name)
 }
 
-func (c *cassette) read() {
-	var fileData, jsonData []byte
-
-	fileData, _ = ioutil.ReadFile(c.Filename())
-
-	if c.Gzip {
-		var data bytes.Buffer
-		err := gunzipWrite(&data, fileData)
-		if err != nil {
-			panic("httpvcr: gzip read failed")
-		}
-		jsonData = data.Bytes()
-	} else {
-		jsonData = fileData
-	}
-
-	err := json.Unmarshal(jsonData, c)
+func (c *cassette) read() error {
+	episodes, err := c.store().Load(c.name)
 	if err != nil {
-		panic("httpvcr: cannot parse json!")
+		return err
 	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Episodes = episodes
 	c.episodeMatcher.Init(c.Episodes)
+	return nil
 }
 
-func (c *cassette) write() {
-	jsonData, _ := json.Marshal(c)
-
-	var jsonOut bytes.Buffer
-	json.Indent(&jsonOut, jsonData, "", "  ")
-
-	os.MkdirAll("fixtures/vcr", 0755)
-
-	var fileOut bytes.Buffer
+func (c *cassette) write() error {
+	c.mu.Lock()
+	episodes := c.Episodes
+	c.mu.Unlock()
+	return c.store().Save(c.name, episodes)
+}
 
-	if c.Gzip {
-		err := gzipWrite(&fileOut, jsonOut.Bytes())
-		if err != nil {
-			panic("httpvcr: gzip write failed")
-		}
-	} else {
-		fileOut = jsonOut
-	}
+// addEpisode appends e to the cassette, safe for concurrent use during
+// ModeRecord.
+func (c *cassette) addEpisode(e Episode) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Episodes = append(c.Episodes, e)
+}
 
-	err := ioutil.WriteFile(c.Filename(), fileOut.Bytes(), 0644)
-	if err != nil {
-		panic("httpvcr: cannot write cassette file!")
-	}
+func (c *cassette) setMatcher(m EpisodeMatcher) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.episodeMatcher = m
 }
 
-func (c *cassette) matchEpisode(request *VCRRequest) (*Episode, error) {
-	return c.episodeMatcher.MatchEpisode(request)
+func (c *cassette) matchEpisode(r *http.Request, request *VCRRequest) (*Episode, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.episodeMatcher.MatchEpisode(r, request)
 }
 
-func panicEpisodeMismatch(request *VCRRequest, field string, expected string, actual string) {
-	panic(fmt.Sprintf(
-		"httpvcr: problem with episode for %s %s\n  episode %s does not match:\n  expected: %s\n  but got: %s",
-		request.Method,
-		request.URL,
-		field,
-		expected,
-		actual,
-	))
+func (c *cassette) episodeCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.Episodes)
 }
 
 // Write gzipped data to a Writer
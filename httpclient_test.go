@@ -0,0 +1,69 @@
+package httpvcr
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPClient(t *testing.T) {
+	testBegin(t)
+
+	ts := testServer()
+	defer ts.Close()
+
+	originalTransport := http.DefaultTransport
+
+	vcr := New("test_cassette", V2Options)
+	client := vcr.HTTPClient()
+
+	assert.Nil(t, vcr.Start(context.Background()))
+	resp, err := client.Get(ts.URL)
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "0:GET:/:''", string(body))
+	assert.Nil(t, vcr.Stop())
+
+	assert.Nil(t, vcr.Start(context.Background()))
+	defer vcr.Stop()
+	resp, err = client.Get(ts.URL)
+	assert.Nil(t, err)
+	body, _ = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "0:GET:/:''", string(body))
+
+	// V2Options does not touch the global default transport.
+	assert.Equal(t, originalTransport, http.DefaultTransport)
+}
+
+func TestWrapCustomTransport(t *testing.T) {
+	testBegin(t)
+
+	ts := testServer()
+	defer ts.Close()
+
+	vcr := New("test_cassette", V2Options)
+	customTransport := &http.Transport{DisableKeepAlives: true}
+	client := &http.Client{Transport: vcr.Wrap(customTransport)}
+
+	assert.Nil(t, vcr.Start(context.Background()))
+	resp, err := client.Get(ts.URL)
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "0:GET:/:''", string(body))
+	assert.Nil(t, vcr.Stop())
+
+	// Replay works the same way, without ever touching http.DefaultTransport.
+	assert.Nil(t, vcr.Start(context.Background()))
+	defer vcr.Stop()
+	resp, err = client.Get(ts.URL)
+	assert.Nil(t, err)
+	body, _ = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "0:GET:/:''", string(body))
+}
@@ -21,16 +21,32 @@ const (
 // Useful for adding row-limits in integration tests.
 type RequestModifierFunc func(request *http.Request)
 
+// BeforeRequestFunc is called on every request, during both recording and
+// replay, before it is sent (or matched). Unlike RequestModifierFunc it is
+// told the VCR's current mode, so callers can rewrite a request consistently
+// in both directions, e.g. with ModifyHTTPRequestBody.
+type BeforeRequestFunc func(mode Mode, request *http.Request)
+
+// BeforeSaveFunc is called on every episode immediately before it is
+// written to the cassette, giving callers a last chance to inspect or
+// scrub it. It runs after FilterResponseBody/FilterRequestHeader/
+// FilterResponseHeader have already been applied.
+type BeforeSaveFunc func(episode *Episode)
+
 type HTTPVCR struct {
 	options Options
 
 	ctx       context.Context
 	ctxCancel context.CancelFunc
 
-	mode            Mode
-	Cassette        *cassette
-	FilterMap       map[string]string
-	RequestModifier RequestModifierFunc
+	mode                  Mode
+	Cassette              *cassette
+	FilterMap             map[string]string
+	RequestHeaderFilters  map[string]string
+	ResponseHeaderFilters map[string]string
+	RequestModifier       RequestModifierFunc
+	BeforeRequest         BeforeRequestFunc
+	BeforeSave            BeforeSaveFunc
 
 	originalTransport http.RoundTripper
 
@@ -39,30 +55,67 @@ type HTTPVCR struct {
 
 type Options struct {
 	HTTPDefaultOverride bool
+	// Store controls where and how cassettes are persisted. Defaults to a
+	// FileStore writing indented JSON under fixtures/vcr.
+	Store CassetteStore
+	// ReplayTiming replays a streamed response body with the same
+	// inter-arrival delays between chunks it was recorded with, instead of
+	// returning the whole body in one buffered read.
+	ReplayTiming bool
 }
 
 var DefaultOptions = Options{
 	HTTPDefaultOverride: true,
 }
 
+// V2Options is a safer alternative to DefaultOptions: it does not globally
+// override http.DefaultTransport, which breaks any code that builds its
+// own *http.Client (most cloud SDKs do). Combine it with HTTPClient or
+// Wrap to inject the VCR explicitly instead.
+var V2Options = Options{
+	HTTPDefaultOverride: false,
+}
+
 func New(cassetteName string, opts ...Options) *HTTPVCR {
 	options := DefaultOptions
 	if len(opts) > 0 {
 		options = opts[0]
 	}
 
+	store := options.Store
+	if store == nil {
+		store = NewJSONFileStore("")
+	}
+
 	return &HTTPVCR{
-		options:   options,
-		mode:      ModeStopped,
-		Cassette:  &cassette{name: cassetteName},
-		FilterMap: make(map[string]string),
+		options:               options,
+		mode:                  ModeStopped,
+		Cassette:              &cassette{name: cassetteName, episodeMatcher: &DefaultEpisodeMatcher{}, Store: store},
+		FilterMap:             make(map[string]string),
+		RequestHeaderFilters:  make(map[string]string),
+		ResponseHeaderFilters: make(map[string]string),
 	}
 }
 
+// SetMatcher overrides the strategy used to pair incoming requests with
+// recorded episodes during replay. Call it before Start; it has no effect
+// on a running session. Defaults to &DefaultEpisodeMatcher{}, which
+// requires method, URL and body to match exactly and replays episodes in
+// recording order.
+func (v *HTTPVCR) SetMatcher(m EpisodeMatcher) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.mode != ModeStopped {
+		return
+	}
+	v.Cassette.setMatcher(m)
+}
+
 // Start starts a VCR session with the given cassette name.
-// Records episodes if the cassette file does not exists.
-// Otherwise plays back recorded episodes.
-func (v *HTTPVCR) Start(ctx context.Context) {
+// Records episodes if the cassette does not exist in the configured Store.
+// Otherwise plays back recorded episodes. Returns an error if the cassette
+// exists but cannot be loaded.
+func (v *HTTPVCR) Start(ctx context.Context) error {
 	v.mu.Lock()
 	defer v.mu.Unlock()
 
@@ -72,30 +125,40 @@ func (v *HTTPVCR) Start(ctx context.Context) {
 
 	v.ctx, v.ctxCancel = context.WithCancel(ctx)
 
-	v.originalTransport = http.DefaultTransport
+	// A prior call to Wrap already picked the real transport to fall back
+	// to; don't clobber it.
+	if v.originalTransport == nil {
+		v.originalTransport = http.DefaultTransport
+	}
 	if v.options.HTTPDefaultOverride {
 		http.DefaultTransport = v
 	}
 
 	if v.Cassette.Exists() {
+		if err := v.Cassette.read(); err != nil {
+			v.mode = ModeStopped
+			return err
+		}
 		v.mode = ModeReplay
-		v.Cassette.read()
 	} else {
 		v.mode = ModeRecord
 	}
+	return nil
 }
 
-// Stop stops the VCR session and writes the cassette file (when recording)
-func (v *HTTPVCR) Stop() {
+// Stop stops the VCR session and writes the cassette (when recording).
+// Returns an error if the cassette could not be saved.
+func (v *HTTPVCR) Stop() error {
 	v.mu.Lock()
 	defer v.mu.Unlock()
 
 	if v.mode == ModeStopped {
-		return
+		return nil
 	}
 
+	var err error
 	if v.mode == ModeRecord {
-		v.Cassette.write()
+		err = v.Cassette.write()
 	}
 
 	if v.options.HTTPDefaultOverride && v.originalTransport != nil {
@@ -104,6 +167,7 @@ func (v *HTTPVCR) Stop() {
 
 	v.mode = ModeStopped
 	v.ctxCancel()
+	return err
 }
 
 func (v *HTTPVCR) Mode() Mode {
@@ -112,6 +176,24 @@ func (v *HTTPVCR) Mode() Mode {
 	return v.mode
 }
 
+// HTTPClient returns an *http.Client that records/replays through the VCR.
+// Use it instead of overriding http.DefaultTransport when the code under
+// test constructs its own http.Client, e.g. most cloud SDKs.
+func (v *HTTPVCR) HTTPClient() *http.Client {
+	return &http.Client{Transport: v}
+}
+
+// Wrap returns an http.RoundTripper that records/replays through the VCR,
+// falling back to rt for the live call while recording. Use it to inject
+// the VCR into a RoundTripper built by another library (custom TLS config,
+// proxy, ...) without touching http.DefaultTransport.
+func (v *HTTPVCR) Wrap(rt http.RoundTripper) http.RoundTripper {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.originalTransport = rt
+	return v
+}
+
 // FilterData allows replacement of sensitive data with a dummy-string
 func (v *HTTPVCR) FilterResponseBody(original string, replacement string) {
 	v.mu.Lock()
@@ -119,9 +201,34 @@ func (v *HTTPVCR) FilterResponseBody(original string, replacement string) {
 	v.FilterMap[original] = replacement
 }
 
+// FilterRequestHeader replaces the value of the named request header with
+// replacement before an episode is saved to the cassette. Use it to scrub
+// credentials (Authorization, X-Api-Key, ...) out of recorded requests.
+func (v *HTTPVCR) FilterRequestHeader(name string, replacement string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.RequestHeaderFilters[name] = replacement
+}
+
+// FilterResponseHeader replaces the value of the named response header with
+// replacement before an episode is saved to the cassette. Use it to scrub
+// values such as Set-Cookie out of recorded responses.
+func (v *HTTPVCR) FilterResponseHeader(name string, replacement string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.ResponseHeaderFilters[name] = replacement
+}
+
+func filterHeader(header http.Header, filters map[string]string) {
+	for name, replacement := range filters {
+		if header.Get(name) != "" {
+			header.Set(name, replacement)
+		}
+	}
+}
+
 func (v *HTTPVCR) RoundTrip(request *http.Request) (*http.Response, error) {
-	vcrReq := newVCRRequest(request, v.FilterMap)
-	var vcrRes *vcrResponse
+	var vcrRes *VCRResponse
 
 	if v.ctx.Err() == context.Canceled {
 		return nil, errors.Errorf("httpvcr: stopped")
@@ -134,29 +241,60 @@ func (v *HTTPVCR) RoundTrip(request *http.Request) (*http.Response, error) {
 	if v.RequestModifier != nil {
 		v.RequestModifier(request)
 	}
+	if v.BeforeRequest != nil {
+		v.BeforeRequest(v.mode, request)
+	}
+
+	// vcrReq is built after the modifier hooks above run, so a request
+	// rewritten on the way out (e.g. via ModifyHTTPRequestBody) is recorded
+	// - and matched against on replay - in its rewritten form.
+	vcrReq := newVCRRequest(request, v.FilterMap)
 
 	if v.mode == ModeRecord {
+		// vcrReq.Header is already a clone (it never aliases request.Header),
+		// so filtering it here to scrub the recorded copy is safe even
+		// before the live round trip goes out.
+		filterHeader(vcrReq.Header, v.RequestHeaderFilters)
+
 		response, err := v.originalTransport.RoundTrip(request)
 		if err != nil {
+			e := Episode{Request: vcrReq, Error: newRecordedError(err)}
+			if v.BeforeSave != nil {
+				v.BeforeSave(&e)
+			}
+			v.Cassette.addEpisode(e)
 			return nil, err
 		}
 		vcrRes = newVCRResponse(response)
 
-		e := episode{Request: vcrReq, Response: vcrRes}
-		v.Cassette.Episodes = append(v.Cassette.Episodes, e)
+		// vcrRes is also what gets returned to the caller below, so the
+		// response filter runs against a further clone - redacting it in
+		// place would corrupt the live response returned during recording.
+		savedRes := *vcrRes
+		savedRes.Header = vcrRes.Header.Clone()
+		filterHeader(savedRes.Header, v.ResponseHeaderFilters)
+
+		e := Episode{Request: vcrReq, Response: &savedRes}
+		if v.BeforeSave != nil {
+			v.BeforeSave(&e)
+		}
+		v.Cassette.addEpisode(e)
 
 	} else {
-		e := v.Cassette.matchEpisode(vcrReq)
+		e, _ := v.Cassette.matchEpisode(request, vcrReq)
+		if e.Error != nil {
+			return nil, e.Error.asError()
+		}
 		vcrRes = e.Response
 	}
 
 	if v.mode == ModeReplay {
-		if len(v.Cassette.Episodes) == 0 {
-			v.Stop()
+		if v.Cassette.episodeCount() == 0 {
+			_ = v.Stop()
 		}
 	}
 
-	return vcrRes.httpResponse(), nil
+	return vcrRes.httpResponse(v.options.ReplayTiming), nil
 }
 
 func (v *HTTPVCR) Done() <-chan struct{} {
@@ -10,6 +10,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -53,9 +54,9 @@ func testAllRequests(t *testing.T, urlBase string) {
 	assert.Equal(t, "0:GET:/:''", body)
 	assert.Equal(t, 200, response.StatusCode)
 	assert.Equal(t, "200 OK", response.Status)
-	assert.Equal(t, "HTTP/1.0", response.Proto)
+	assert.Equal(t, "HTTP/1.1", response.Proto)
 	assert.Equal(t, 1, response.ProtoMajor)
-	assert.Equal(t, 0, response.ProtoMinor)
+	assert.Equal(t, 1, response.ProtoMinor)
 	assert.Equal(t, len(body), int(response.ContentLength))
 	assert.Equal(t, []string{"yes"}, response.Header["Test"])
 
@@ -109,10 +110,11 @@ func TestVCR(t *testing.T) {
 	vcr.FilterResponseBody("secret-key", "dummy-key")
 	vcr.BeforeRequest = requestMod
 
-	vcr.Start(context.Background())
+	err := vcr.Start(context.Background())
+	assert.Nil(t, err)
 	assert.Equal(t, ModeRecord, vcr.Mode())
 	testAllRequests(t, ts.URL)
-	vcr.Stop()
+	assert.Nil(t, vcr.Stop())
 
 	// this only works because the key is the only body content.
 	// otherwise the base64 alignment would be off.
@@ -120,10 +122,11 @@ func TestVCR(t *testing.T) {
 	assert.Contains(t, string(data), base64.StdEncoding.EncodeToString([]byte("dummy-key")))
 	assert.NotContains(t, string(data), base64.StdEncoding.EncodeToString([]byte("secret-key")))
 
-	vcr.Start(context.Background())
+	err = vcr.Start(context.Background())
+	assert.Nil(t, err)
 	assert.Equal(t, ModeReplay, vcr.Mode())
 	testAllRequests(t, ts.URL)
-	vcr.Stop()
+	assert.Nil(t, vcr.Stop())
 }
 
 func TestNoSession(t *testing.T) {
@@ -144,10 +147,10 @@ func TestNoEpisodesLeft(t *testing.T) {
 	}()
 
 	vcr := New("test_cassette")
-	vcr.Start(context.Background())
-	vcr.Stop()
+	assert.Nil(t, vcr.Start(context.Background()))
+	assert.Nil(t, vcr.Stop())
 
-	vcr.Start(context.Background())
+	assert.Nil(t, vcr.Start(context.Background()))
 	defer vcr.Stop()
 	testRequest(t, "http://1.2.3.4", nil)
 }
@@ -160,14 +163,14 @@ func TestEpisodesDoNotMatch(t *testing.T) {
 
 	vcr := New("test_cassette")
 	assert.Equal(t, ModeStopped, vcr.Mode())
-	vcr.Start(context.Background())
+	assert.Nil(t, vcr.Start(context.Background()))
 	assert.Equal(t, ModeRecord, vcr.Mode())
 	testRequest(t, ts.URL, nil)
-	vcr.Stop()
+	assert.Nil(t, vcr.Stop())
 
 	// Method mismatch
 	func() {
-		vcr.Start(context.Background())
+		assert.Nil(t, vcr.Start(context.Background()))
 		defer vcr.Stop()
 
 		defer func() {
@@ -185,7 +188,7 @@ func TestEpisodesDoNotMatch(t *testing.T) {
 			assert.Equal(t, fmt.Sprintf("httpvcr: problem with episode for GET %s\n  episode URL does not match:\n  expected: %v\n  but got: %v", otherURL, ts.URL, otherURL), recover())
 		}()
 
-		vcr.Start(context.Background())
+		assert.Nil(t, vcr.Start(context.Background()))
 		defer vcr.Stop()
 		testRequest(t, otherURL, nil)
 	}()
@@ -199,11 +202,11 @@ func TestEpisodesDoNotMatch(t *testing.T) {
 
 		vcr = New("test_cassette2")
 
-		vcr.Start(context.Background())
+		assert.Nil(t, vcr.Start(context.Background()))
 		testRequest(t, ts.URL, &body)
-		vcr.Stop()
+		assert.Nil(t, vcr.Stop())
 
-		vcr.Start(context.Background())
+		assert.Nil(t, vcr.Start(context.Background()))
 		defer vcr.Stop()
 		body = "bar"
 		testRequest(t, ts.URL, &body)
@@ -214,54 +217,157 @@ func TestOriginalRoundTripErrors(t *testing.T) {
 	testBegin(t)
 
 	vcr := New("test_cassette")
-	vcr.Start(context.Background())
-	defer vcr.Stop()
+	assert.Nil(t, vcr.Start(context.Background()))
 
 	_, err := http.Get("xhttp://foo")
 	assert.EqualError(t, err, "Get \"xhttp://foo\": unsupported protocol scheme \"xhttp\"")
+	assert.Nil(t, vcr.Stop())
+
+	// The failed round trip was recorded, so replay reproduces the same
+	// error instead of making a live call or panicking.
+	assert.Nil(t, vcr.Start(context.Background()))
+	defer vcr.Stop()
+
+	_, err = http.Get("xhttp://foo")
+	assert.EqualError(t, err, "Get \"xhttp://foo\": unsupported protocol scheme \"xhttp\"")
 }
 
 func TestFileWriteError(t *testing.T) {
 	testBegin(t)
 
-	defer func() {
-		assert.Equal(t, recover(), "httpvcr: cannot write cassette file!")
-	}()
-
 	vcr := New("test")
-	vcr.Start(context.Background())
-	defer vcr.Stop()
+	assert.Nil(t, vcr.Start(context.Background()))
 
 	err := os.MkdirAll("fixtures/vcr/test.json", 0755)
 	assert.Nil(t, err)
+
+	err = vcr.Stop()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "httpvcr: cannot write cassette file")
 }
 
 func TestFileParseError(t *testing.T) {
 	testBegin(t)
 
-	defer func() {
-		assert.Equal(t, recover(), "httpvcr: cannot parse json!")
-	}()
-
 	os.MkdirAll("fixtures/vcr", 0755)
 	err := ioutil.WriteFile("fixtures/vcr/test.json", []byte("{[}"), 0644)
 	assert.Nil(t, err)
 
 	vcr := New("test")
-	vcr.Start(context.Background())
-	vcr.Stop()
+	err = vcr.Start(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "httpvcr: cannot parse cassette file")
+	assert.Equal(t, ModeStopped, vcr.Mode())
+
+	assert.Nil(t, vcr.Stop())
+}
+
+func TestFilterHeaders(t *testing.T) {
+	testBegin(t)
+
+	ts := testServer()
+	defer ts.Close()
+
+	vcr := New("test_cassette")
+	vcr.FilterRequestHeader("Authorization", "REDACTED")
+	vcr.FilterResponseHeader("Test", "REDACTED")
+
+	var savedHeader string
+	vcr.BeforeSave = func(e *Episode) {
+		savedHeader = e.Request.Header.Get("Authorization")
+	}
+
+	assert.Nil(t, vcr.Start(context.Background()))
+
+	req, err := http.NewRequest("GET", ts.URL, nil)
+	assert.Nil(t, err)
+	req.Header.Set("Authorization", "Bearer secret-token")
+	response, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	response.Body.Close()
+
+	// Filters scrub the recorded copy only; the live caller still sees the
+	// real response header during recording.
+	assert.Equal(t, []string{"yes"}, response.Header["Test"])
+
+	assert.Nil(t, vcr.Stop())
+
+	assert.Equal(t, "REDACTED", savedHeader)
+
+	data, _ := ioutil.ReadFile("fixtures/vcr/test_cassette.json")
+	assert.NotContains(t, string(data), "secret-token")
+	assert.Contains(t, string(data), "REDACTED")
+	assert.NotContains(t, string(data), "\"yes\"")
+}
+
+func TestSetMatcher(t *testing.T) {
+	testBegin(t)
+
+	ts := testServer()
+	defer ts.Close()
+
+	vcr := New("test_cassette")
+	assert.Nil(t, vcr.Start(context.Background()))
+	testRequest(t, ts.URL+"/?a=1&b=2", nil)
+	assert.Nil(t, vcr.Stop())
+
+	// A custom matcher composed from the built-ins lets us replay a request
+	// whose query parameters were reordered between record and replay.
+	vcr.SetMatcher(NewFuncEpisodeMatcher(MethodPathAndQueryMatcher))
+
+	assert.Nil(t, vcr.Start(context.Background()))
+	defer vcr.Stop()
+	_, body := testRequest(t, ts.URL+"/?b=2&a=1", nil)
+	assert.Equal(t, "0:GET:/:''", body)
+}
+
+func TestConcurrentReplay(t *testing.T) {
+	testBegin(t)
+
+	ts := testServer()
+	defer ts.Close()
+
+	const n = 20
+
+	vcr := New("test_cassette")
+	vcr.SetMatcher(&IndexedEpisodeMatcher{})
+	assert.Nil(t, vcr.Start(context.Background()))
+	for i := 0; i < n; i++ {
+		testRequest(t, fmt.Sprintf("%s/?id=%d", ts.URL, i), nil)
+	}
+	assert.Nil(t, vcr.Stop())
+
+	vcr.SetMatcher(&IndexedEpisodeMatcher{})
+	assert.Nil(t, vcr.Start(context.Background()))
+	defer vcr.Stop()
+
+	results := make([]string, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, body := testRequest(t, fmt.Sprintf("%s/?id=%d", ts.URL, i), nil)
+			results[i] = body
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		assert.Equal(t, fmt.Sprintf("%d:GET:/:''", i), results[i])
+	}
 }
 
 func TestStartTwice(t *testing.T) {
 	testBegin(t)
 
+	vcr := New("test")
+	assert.Nil(t, vcr.Start(context.Background()))
+	defer vcr.Stop()
+
 	defer func() {
 		assert.Equal(t, recover(), "httpvcr: session already started!")
 	}()
 
-	vcr := New("test")
 	vcr.Start(context.Background())
-	vcr.Start(context.Background())
-
-	vcr.Stop()
 }
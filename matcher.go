@@ -0,0 +1,230 @@
+package httpvcr
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+)
+
+// EpisodeMatcher decides which recorded Episode, if any, corresponds to an
+// incoming request during replay. Init is called once per Start() that
+// loads an existing cassette, with the episodes loaded from it; MatchEpisode is then called for every
+// request made while the VCR is in ModeReplay.
+type EpisodeMatcher interface {
+	Init(episodes []Episode)
+	MatchEpisode(r *http.Request, request *VCRRequest) (*Episode, error)
+}
+
+// DefaultEpisodeMatcher replays episodes strictly in recording order and
+// requires the method, URL and body of each request to match exactly.
+type DefaultEpisodeMatcher struct {
+	episodes []Episode
+}
+
+func (m *DefaultEpisodeMatcher) Init(episodes []Episode) {
+	m.episodes = episodes
+}
+
+func (m *DefaultEpisodeMatcher) MatchEpisode(r *http.Request, request *VCRRequest) (*Episode, error) {
+	if len(m.episodes) == 0 {
+		panic("httpvcr: no more episodes!")
+	}
+
+	e := m.episodes[0]
+	expected := e.Request
+
+	if expected.Method != request.Method {
+		panicEpisodeMismatch(request, "Method", expected.Method, request.Method)
+	}
+
+	if expected.URL != request.URL {
+		panicEpisodeMismatch(request, "URL", expected.URL, request.URL)
+	}
+
+	if !bytes.Equal(expected.Body, request.Body) {
+		panicEpisodeMismatch(request, "Body", string(expected.Body[:]), string(request.Body[:]))
+	}
+
+	m.episodes = m.episodes[1:]
+	return &e, nil
+}
+
+func panicEpisodeMismatch(request *VCRRequest, field string, expected string, actual string) {
+	panic(fmt.Sprintf(
+		"httpvcr: problem with episode for %s %s\n  episode %s does not match:\n  expected: %s\n  but got: %s",
+		request.Method,
+		request.URL,
+		field,
+		expected,
+		actual,
+	))
+}
+
+// MatcherFunc reports whether the live request r corresponds to the
+// recorded request e. Several built-in MatcherFuncs are provided below;
+// combine them with ComposeMatchers to build custom matching strategies,
+// following the pattern used by go-vcr/govcr.
+type MatcherFunc func(r *http.Request, e VCRRequest) bool
+
+// ComposeMatchers returns a MatcherFunc that matches only when every one of
+// matchers matches.
+func ComposeMatchers(matchers ...MatcherFunc) MatcherFunc {
+	return func(r *http.Request, e VCRRequest) bool {
+		for _, m := range matchers {
+			if !m(r, e) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MatchMethod matches when the request methods are identical.
+func MatchMethod(r *http.Request, e VCRRequest) bool {
+	return r.Method == e.Method
+}
+
+// MatchURL matches when the request URLs, including any query string, are
+// identical.
+func MatchURL(r *http.Request, e VCRRequest) bool {
+	return r.URL.String() == e.URL
+}
+
+// MatchBody matches when the request bodies are byte-for-byte identical. A
+// nil and an empty body are treated as equal, since some codecs (e.g. YAML)
+// can't round-trip the distinction.
+func MatchBody(r *http.Request, e VCRRequest) bool {
+	return bytes.Equal(bodyOf(r), e.Body)
+}
+
+// MatchPathAndQuery matches when the request paths are identical and the
+// query parameters are identical regardless of the order of distinct
+// parameters. A repeated parameter must carry the same values, the same
+// number of times and in the same order.
+func MatchPathAndQuery(r *http.Request, e VCRRequest) bool {
+	expected, err := url.Parse(e.URL)
+	if err != nil {
+		return false
+	}
+	return r.URL.Path == expected.Path && reflect.DeepEqual(r.URL.Query(), expected.Query())
+}
+
+// MatchHeader returns a MatcherFunc that matches when the named header
+// carries the same value on both requests. Useful for pairing requests by a
+// stable identifier (e.g. X-Request-Id) without requiring the rest of the
+// request to match exactly.
+func MatchHeader(name string) MatcherFunc {
+	return func(r *http.Request, e VCRRequest) bool {
+		return r.Header.Get(name) == e.Header.Get(name)
+	}
+}
+
+// MethodAndURLMatcher matches on method and URL only, ignoring the body.
+// Useful for APIs with non-deterministic request bodies (timestamps,
+// nonces, multipart boundaries).
+var MethodAndURLMatcher = ComposeMatchers(MatchMethod, MatchURL)
+
+// MethodURLAndBodyMatcher matches on method, URL and body, like
+// DefaultEpisodeMatcher's comparison expressed as a composable MatcherFunc.
+// Unlike DefaultEpisodeMatcher, it compares the live request body before
+// any FilterResponseBody replacements are applied to it.
+var MethodURLAndBodyMatcher = ComposeMatchers(MatchMethod, MatchURL, MatchBody)
+
+// MethodPathAndQueryMatcher matches on method, path and query parameters,
+// ignoring query parameter order and the request body. Useful for signed
+// URLs where query parameters are reordered between runs; a parameter
+// whose value changes between runs (e.g. a fresh signature) does not match.
+var MethodPathAndQueryMatcher = ComposeMatchers(MatchMethod, MatchPathAndQuery)
+
+// FuncEpisodeMatcher adapts a MatcherFunc into an EpisodeMatcher. Episodes
+// are searched in recording order and the first one Match reports true for
+// is returned and removed from the pool.
+type FuncEpisodeMatcher struct {
+	Match MatcherFunc
+
+	episodes []Episode
+}
+
+// NewFuncEpisodeMatcher builds a FuncEpisodeMatcher from match. Combine
+// built-in MatcherFuncs with ComposeMatchers to build match, e.g.
+// NewFuncEpisodeMatcher(httpvcr.MethodAndURLMatcher).
+func NewFuncEpisodeMatcher(match MatcherFunc) *FuncEpisodeMatcher {
+	return &FuncEpisodeMatcher{Match: match}
+}
+
+func (m *FuncEpisodeMatcher) Init(episodes []Episode) {
+	m.episodes = episodes
+}
+
+func (m *FuncEpisodeMatcher) MatchEpisode(r *http.Request, request *VCRRequest) (*Episode, error) {
+	for i, e := range m.episodes {
+		if m.Match(r, *e.Request) {
+			m.episodes = append(m.episodes[:i], m.episodes[i+1:]...)
+			return &e, nil
+		}
+	}
+	panic(fmt.Sprintf("httpvcr: no episode matches %s %s", request.Method, request.URL))
+}
+
+// IndexedEpisodeMatcher matches episodes by a hash of method, URL and body
+// instead of popping them from the head of a list, so it tolerates
+// concurrent, out-of-order replay: goroutines, subtests run in parallel, or
+// an http.Client reusing connections. Episode.Consumed marks episodes that
+// have already been matched so they aren't returned twice; Init resets it,
+// so the same cassette can be replayed again from scratch.
+//
+// MatchEpisode is called with the cassette's lock held, so no additional
+// synchronization is needed here.
+type IndexedEpisodeMatcher struct {
+	index map[string][]*Episode
+}
+
+func (m *IndexedEpisodeMatcher) Init(episodes []Episode) {
+	m.index = make(map[string][]*Episode, len(episodes))
+	for i := range episodes {
+		e := &episodes[i]
+		e.Consumed = false
+		key := episodeKey(e.Request.Method, e.Request.URL, e.Request.Body)
+		m.index[key] = append(m.index[key], e)
+	}
+}
+
+func (m *IndexedEpisodeMatcher) MatchEpisode(r *http.Request, request *VCRRequest) (*Episode, error) {
+	key := episodeKey(request.Method, request.URL, request.Body)
+	for _, e := range m.index[key] {
+		if !e.Consumed {
+			e.Consumed = true
+			return e, nil
+		}
+	}
+	panic(fmt.Sprintf("httpvcr: no episode matches %s %s", request.Method, request.URL))
+}
+
+// episodeKey derives a stable matching key from method, URL and body so
+// IndexedEpisodeMatcher can look episodes up regardless of arrival order.
+func episodeKey(method, url string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(url))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// bodyOf returns r's body without consuming it, so matchers can inspect it
+// alongside the rest of the request pipeline.
+func bodyOf(r *http.Request) []byte {
+	if r.Body == nil {
+		return nil
+	}
+	body, err := readBody(r)
+	if err != nil {
+		return nil
+	}
+	return body
+}
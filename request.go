@@ -7,27 +7,41 @@ import (
 )
 
 type VCRRequest struct {
-	// Header is intentionally not included and is not used for episode matching
 	Method string
 	URL    string
+	Header http.Header
 	Body   []byte
 }
 
 func newVCRRequest(request *http.Request, filterMap map[string]string) *VCRRequest {
-	var body []byte
-	if request.Body != nil {
-		body, _ = ioutil.ReadAll(request.Body)
-		request.Body.Close()
-		request.Body = ioutil.NopCloser(bytes.NewBuffer(body))
-
-		for original, replacement := range filterMap {
-			body = bytes.Replace(body, []byte(original), []byte(replacement), -1)
-		}
+	body, _ := readBody(request)
+
+	for original, replacement := range filterMap {
+		body = bytes.Replace(body, []byte(original), []byte(replacement), -1)
 	}
 
 	return &VCRRequest{
 		Method: request.Method,
 		URL:    request.URL.String(),
+		Header: request.Header.Clone(),
 		Body:   body,
 	}
 }
+
+// readBody returns request's body without permanently consuming it: the
+// body is read in full and then replaced with a fresh reader over the same
+// bytes, so later code (matchers, the real round trip) can still read it.
+func readBody(request *http.Request) ([]byte, error) {
+	if request.Body == nil {
+		return nil, nil
+	}
+
+	body, err := ioutil.ReadAll(request.Body)
+	request.Body.Close()
+	request.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
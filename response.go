@@ -2,42 +2,141 @@ package httpvcr
 
 import (
 	"bytes"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"time"
 )
 
+// responseChunk is one piece of a streamed response body, tagged with how
+// long after the previous chunk (or the start of the response) it arrived.
+// Recording in chunks instead of one flat read lets replay reproduce SSE,
+// gRPC-over-HTTP or large downloads instead of buffering the whole body.
+type responseChunk struct {
+	Data  []byte
+	Delay time.Duration
+}
+
 type VCRResponse struct {
-	Status        string
-	StatusCode    int
-	ContentLength int64
-	Header        http.Header
-	Body          []byte
+	Status           string
+	StatusCode       int
+	ContentLength    int64
+	Header           http.Header
+	Trailer          http.Header
+	Proto            string
+	ProtoMajor       int
+	ProtoMinor       int
+	TransferEncoding []string
+	Body             []byte
+	// Chunks holds the body split the way it was originally read, with
+	// inter-arrival delays, so replay can reproduce streaming timing when
+	// Options.ReplayTiming is set. Omitted (and ignored) for cassettes
+	// recorded before this field existed, which replay as a single chunk.
+	Chunks []responseChunk `json:",omitempty"`
 }
 
 func newVCRResponse(response *http.Response) *VCRResponse {
 	var body []byte
+	var chunks []responseChunk
+
 	if response.Body != nil {
-		body, _ = ioutil.ReadAll(response.Body)
+		start := time.Now()
+		last := start
+		buf := make([]byte, 32*1024)
+
+		for {
+			n, err := response.Body.Read(buf)
+			if n > 0 {
+				data := append([]byte(nil), buf[:n]...)
+				now := time.Now()
+				chunks = append(chunks, responseChunk{Data: data, Delay: now.Sub(last)})
+				body = append(body, data...)
+				last = now
+			}
+			if err != nil {
+				break
+			}
+		}
+		response.Body.Close()
 	}
 
 	return &VCRResponse{
-		Status:        response.Status,
-		StatusCode:    response.StatusCode,
-		Header:        response.Header,
-		ContentLength: response.ContentLength,
-		Body:          body,
+		Status:           response.Status,
+		StatusCode:       response.StatusCode,
+		Header:           response.Header.Clone(),
+		Trailer:          response.Trailer.Clone(),
+		Proto:            response.Proto,
+		ProtoMajor:       response.ProtoMajor,
+		ProtoMinor:       response.ProtoMinor,
+		TransferEncoding: response.TransferEncoding,
+		ContentLength:    response.ContentLength,
+		Body:             body,
+		Chunks:           chunks,
 	}
 }
 
-func (vr *VCRResponse) httpResponse() *http.Response {
+// httpResponse rebuilds an *http.Response from the recorded episode. When
+// replayTiming is true and the cassette recorded more than one chunk, the
+// body is replayed with the same inter-arrival delays it was recorded
+// with; otherwise (and for cassettes recorded before chunking existed) the
+// whole body is returned as a single buffered read, as before.
+func (vr *VCRResponse) httpResponse(replayTiming bool) *http.Response {
+	proto, protoMajor, protoMinor := vr.Proto, vr.ProtoMajor, vr.ProtoMinor
+	if proto == "" {
+		proto, protoMajor, protoMinor = "HTTP/1.0", 1, 0
+	}
+
+	var body io.ReadCloser
+	if replayTiming && len(vr.Chunks) > 1 {
+		body = newTimedChunkReader(vr.Chunks)
+	} else {
+		body = ioutil.NopCloser(bytes.NewBuffer(vr.Body))
+	}
+
 	return &http.Response{
-		Status:        vr.Status,
-		StatusCode:    vr.StatusCode,
-		Proto:         "HTTP/1.0",
-		ProtoMajor:    1,
-		ProtoMinor:    0,
-		Header:        vr.Header,
-		ContentLength: vr.ContentLength,
-		Body:          ioutil.NopCloser(bytes.NewBuffer(vr.Body)),
+		Status:           vr.Status,
+		StatusCode:       vr.StatusCode,
+		Proto:            proto,
+		ProtoMajor:       protoMajor,
+		ProtoMinor:       protoMinor,
+		Header:           vr.Header,
+		Trailer:          vr.Trailer,
+		TransferEncoding: vr.TransferEncoding,
+		ContentLength:    vr.ContentLength,
+		Body:             body,
 	}
 }
+
+// timedChunkReader replays a recorded response body chunk by chunk,
+// sleeping for each chunk's recorded delay before returning it.
+type timedChunkReader struct {
+	chunks []responseChunk
+	cur    []byte
+}
+
+func newTimedChunkReader(chunks []responseChunk) io.ReadCloser {
+	return &timedChunkReader{chunks: chunks}
+}
+
+func (r *timedChunkReader) Read(p []byte) (int, error) {
+	for len(r.cur) == 0 {
+		if len(r.chunks) == 0 {
+			return 0, io.EOF
+		}
+
+		next := r.chunks[0]
+		r.chunks = r.chunks[1:]
+		if next.Delay > 0 {
+			time.Sleep(next.Delay)
+		}
+		r.cur = next.Data
+	}
+
+	n := copy(p, r.cur)
+	r.cur = r.cur[n:]
+	return n, nil
+}
+
+func (r *timedChunkReader) Close() error {
+	return nil
+}
@@ -0,0 +1,90 @@
+package httpvcr
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func streamingServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, _ := w.(http.Flusher)
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, "chunk%d;", i)
+			if flusher != nil {
+				flusher.Flush()
+			}
+			time.Sleep(30 * time.Millisecond)
+		}
+	}))
+}
+
+func TestStreamingResponseReplaysTiming(t *testing.T) {
+	testBegin(t)
+
+	ts := streamingServer()
+	defer ts.Close()
+
+	vcr := New("test_cassette", Options{HTTPDefaultOverride: true, ReplayTiming: true})
+
+	assert.Nil(t, vcr.Start(context.Background()))
+	resp, err := http.Get(ts.URL)
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "chunk0;chunk1;chunk2;", string(body))
+	assert.Nil(t, vcr.Stop())
+
+	assert.Nil(t, vcr.Start(context.Background()))
+	defer vcr.Stop()
+
+	start := time.Now()
+	resp, err = http.Get(ts.URL)
+	assert.Nil(t, err)
+	body, _ = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	elapsed := time.Since(start)
+
+	assert.Equal(t, "chunk0;chunk1;chunk2;", string(body))
+	// The two recorded 30ms gaps between the three chunks should have been reproduced, not
+	// collapsed into a single buffered read.
+	assert.GreaterOrEqual(t, elapsed, 60*time.Millisecond)
+}
+
+func TestStreamingResponseWithoutReplayTiming(t *testing.T) {
+	testBegin(t)
+
+	ts := streamingServer()
+	defer ts.Close()
+
+	// ReplayTiming defaults to false: chunks are still recorded, but
+	// replay returns the whole body in one buffered read, as before.
+	vcr := New("test_cassette")
+
+	assert.Nil(t, vcr.Start(context.Background()))
+	resp, err := http.Get(ts.URL)
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "chunk0;chunk1;chunk2;", string(body))
+	assert.Nil(t, vcr.Stop())
+
+	assert.Nil(t, vcr.Start(context.Background()))
+	defer vcr.Stop()
+
+	start := time.Now()
+	resp, err = http.Get(ts.URL)
+	assert.Nil(t, err)
+	body, _ = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	elapsed := time.Since(start)
+
+	assert.Equal(t, "chunk0;chunk1;chunk2;", string(body))
+	assert.Less(t, elapsed, 60*time.Millisecond)
+}
@@ -0,0 +1,72 @@
+package httpvcr
+
+import (
+	"context"
+	"errors"
+	"net/url"
+)
+
+// sentinels maps the well-known package-level error values we want replay
+// to reconstruct exactly, keyed by a stable name, so a recorded error can
+// still satisfy errors.Is after a round-trip through the cassette.
+var sentinels = map[string]error{
+	"context canceled":          context.Canceled,
+	"context deadline exceeded": context.DeadlineExceeded,
+}
+
+// sentinelName returns the key sentinels is keyed by for err, or "" if err
+// doesn't match any of them.
+func sentinelName(err error) string {
+	for name, sentinel := range sentinels {
+		if errors.Is(err, sentinel) {
+			return name
+		}
+	}
+	return ""
+}
+
+// RecordedError is a serializable snapshot of an error returned by the
+// underlying transport during ModeRecord, so that replay can reproduce an
+// equivalent failure instead of silently making a live call or panicking
+// with "no more episodes".
+type RecordedError struct {
+	// Op and URL are populated when the original error was a *url.Error
+	// (the type net/http wraps most transport errors in), so replay can
+	// reconstruct one instead of a generic error.
+	Op      string
+	URL     string
+	Message string
+	// Sentinel names a well-known error (see sentinels) that asError should
+	// reconstruct exactly, so errors.Is(replayedErr, context.Canceled) and
+	// similar checks still succeed after replay. Empty when the original
+	// error has no known sentinel equivalent.
+	Sentinel string
+}
+
+// newRecordedError captures err in a form that can be serialized to a
+// cassette and later reconstructed by asError. Returns nil for a nil err.
+func newRecordedError(err error) *RecordedError {
+	if err == nil {
+		return nil
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return &RecordedError{Op: urlErr.Op, URL: urlErr.URL, Message: urlErr.Err.Error(), Sentinel: sentinelName(urlErr.Err)}
+	}
+
+	return &RecordedError{Message: err.Error(), Sentinel: sentinelName(err)}
+}
+
+// asError reconstructs an error equivalent to the one originally recorded.
+func (e *RecordedError) asError() error {
+	inner, ok := sentinels[e.Sentinel]
+	if !ok {
+		inner = errors.New(e.Message)
+	}
+
+	if e.Op != "" || e.URL != "" {
+		return &url.Error{Op: e.Op, URL: e.URL, Err: inner}
+	}
+	return inner
+}
@@ -0,0 +1,60 @@
+package httpvcr
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordAndReplay runs fn against vcr once in ModeRecord and once replayed from the
+// resulting cassette, returning the error observed each time.
+func recordAndReplay(t *testing.T, name string, fn func() error) (recordErr, replayErr error) {
+	vcr := New(name)
+	assert.Nil(t, vcr.Start(context.Background()))
+	recordErr = fn()
+	assert.Nil(t, vcr.Stop())
+
+	assert.Nil(t, vcr.Start(context.Background()))
+	defer vcr.Stop()
+	replayErr = fn()
+
+	return recordErr, replayErr
+}
+
+func TestRoundTripErrorDNSFailure(t *testing.T) {
+	testBegin(t)
+
+	recordErr, replayErr := recordAndReplay(t, "test_cassette", func() error {
+		_, err := http.Get("http://thisdomaindoesnotexist.invalid")
+		return err
+	})
+
+	assert.Error(t, recordErr)
+	assert.EqualError(t, replayErr, recordErr.Error())
+}
+
+func TestRoundTripErrorContextCancelled(t *testing.T) {
+	testBegin(t)
+
+	recordErr, replayErr := recordAndReplay(t, "test_cassette", func() error {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		req, err := http.NewRequest("GET", "http://example.com", nil)
+		assert.Nil(t, err)
+
+		_, err = http.DefaultClient.Do(req.WithContext(ctx))
+		return err
+	})
+
+	assert.Error(t, recordErr)
+	assert.True(t, errors.Is(recordErr, context.Canceled))
+	assert.EqualError(t, replayErr, recordErr.Error())
+
+	// Sentinel identity, not just the message, must survive the round-trip:
+	// callers commonly branch on errors.Is(err, context.Canceled).
+	assert.True(t, errors.Is(replayErr, context.Canceled))
+}
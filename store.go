@@ -0,0 +1,307 @@
+package httpvcr
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// CassetteStore persists and retrieves the episodes recorded for a named
+// cassette. HTTPVCR depends only on this interface, so callers can swap in
+// whatever storage format or medium suits their tests.
+type CassetteStore interface {
+	// Exists reports whether a cassette with the given name has already
+	// been saved.
+	Exists(name string) bool
+	// Load returns the episodes previously saved for name.
+	Load(name string) ([]Episode, error)
+	// Save persists episodes under name, overwriting any previous content.
+	Save(name string, episodes []Episode) error
+}
+
+// codec marshals and unmarshals a cassette's episodes to and from bytes, so
+// FileStore can support multiple on-disk formats.
+type codec interface {
+	Marshal(episodes []Episode) ([]byte, error)
+	Unmarshal(data []byte, episodes *[]Episode) error
+	Ext() string
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(episodes []Episode) ([]byte, error) {
+	data, err := json.Marshal(episodes)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "", "  "); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func (jsonCodec) Unmarshal(data []byte, episodes *[]Episode) error {
+	return json.Unmarshal(data, episodes)
+}
+
+func (jsonCodec) Ext() string { return "json" }
+
+// yamlCodec stores request/response bodies as plain strings, the way the
+// ruby/php/go-vcr family of libraries does, instead of yaml.v2's default of
+// marshaling a []byte as a sequence of integers.
+type yamlCodec struct{}
+
+// yamlEpisode mirrors Episode for YAML purposes, with Body fields decoded
+// to/from a plain string instead of the raw []byte on VCRRequest/VCRResponse.
+type yamlEpisode struct {
+	Request  yamlVCRRequest
+	Response *yamlVCRResponse `yaml:",omitempty"`
+	Error    *RecordedError   `yaml:",omitempty"`
+}
+
+type yamlVCRRequest struct {
+	Method string
+	URL    string
+	Header http.Header `yaml:",omitempty"`
+	Body   string      `yaml:",omitempty"`
+}
+
+type yamlVCRResponse struct {
+	Status           string
+	StatusCode       int
+	ContentLength    int64
+	Header           http.Header `yaml:",omitempty"`
+	Trailer          http.Header `yaml:",omitempty"`
+	Proto            string      `yaml:",omitempty"`
+	ProtoMajor       int         `yaml:",omitempty"`
+	ProtoMinor       int         `yaml:",omitempty"`
+	TransferEncoding []string    `yaml:",omitempty"`
+	Body             string      `yaml:",omitempty"`
+	// Chunks carries the recorded body split with inter-arrival delays, the
+	// same as VCRResponse.Chunks, so Options.ReplayTiming isn't silently a
+	// no-op for YAML-backed cassettes.
+	Chunks []responseChunk `yaml:",omitempty"`
+}
+
+func toYAMLEpisode(e Episode) yamlEpisode {
+	out := yamlEpisode{
+		Request: yamlVCRRequest{
+			Method: e.Request.Method,
+			URL:    e.Request.URL,
+			Header: e.Request.Header,
+			Body:   string(e.Request.Body),
+		},
+		Error: e.Error,
+	}
+	if e.Response != nil {
+		out.Response = &yamlVCRResponse{
+			Status:           e.Response.Status,
+			StatusCode:       e.Response.StatusCode,
+			ContentLength:    e.Response.ContentLength,
+			Header:           e.Response.Header,
+			Trailer:          e.Response.Trailer,
+			Proto:            e.Response.Proto,
+			ProtoMajor:       e.Response.ProtoMajor,
+			ProtoMinor:       e.Response.ProtoMinor,
+			TransferEncoding: e.Response.TransferEncoding,
+			Body:             string(e.Response.Body),
+			Chunks:           e.Response.Chunks,
+		}
+	}
+	return out
+}
+
+func fromYAMLEpisode(y yamlEpisode) Episode {
+	out := Episode{
+		Request: &VCRRequest{
+			Method: y.Request.Method,
+			URL:    y.Request.URL,
+			Header: y.Request.Header,
+			Body:   []byte(y.Request.Body),
+		},
+		Error: y.Error,
+	}
+	if y.Response != nil {
+		out.Response = &VCRResponse{
+			Status:           y.Response.Status,
+			StatusCode:       y.Response.StatusCode,
+			ContentLength:    y.Response.ContentLength,
+			Header:           y.Response.Header,
+			Trailer:          y.Response.Trailer,
+			Proto:            y.Response.Proto,
+			ProtoMajor:       y.Response.ProtoMajor,
+			ProtoMinor:       y.Response.ProtoMinor,
+			TransferEncoding: y.Response.TransferEncoding,
+			Body:             []byte(y.Response.Body),
+			Chunks:           y.Response.Chunks,
+		}
+	}
+	return out
+}
+
+func (yamlCodec) Marshal(episodes []Episode) ([]byte, error) {
+	yamlEpisodes := make([]yamlEpisode, len(episodes))
+	for i, e := range episodes {
+		yamlEpisodes[i] = toYAMLEpisode(e)
+	}
+	return yaml.Marshal(yamlEpisodes)
+}
+
+func (yamlCodec) Unmarshal(data []byte, episodes *[]Episode) error {
+	var yamlEpisodes []yamlEpisode
+	if err := yaml.Unmarshal(data, &yamlEpisodes); err != nil {
+		return err
+	}
+
+	out := make([]Episode, len(yamlEpisodes))
+	for i, y := range yamlEpisodes {
+		out[i] = fromYAMLEpisode(y)
+	}
+	*episodes = out
+	return nil
+}
+
+func (yamlCodec) Ext() string { return "yaml" }
+
+// FileStore persists cassettes as files on disk, one file per cassette
+// name, encoded with Codec and optionally gzip-compressed.
+type FileStore struct {
+	// Dir is the directory cassette files are read from and written to.
+	// Defaults to "fixtures/vcr".
+	Dir string
+	// Codec controls the on-disk format. Defaults to JSON.
+	Codec codec
+	// Gzip transparently compresses/decompresses the file content.
+	Gzip bool
+}
+
+// NewJSONFileStore returns a FileStore that writes cassettes as indented
+// JSON under dir, matching httpvcr's original on-disk layout.
+func NewJSONFileStore(dir string) *FileStore {
+	return &FileStore{Dir: dir, Codec: jsonCodec{}}
+}
+
+// NewYAMLFileStore returns a FileStore that writes cassettes as YAML under
+// dir, compatible with the on-disk schema used by the ruby/php/go-vcr
+// family of libraries.
+func NewYAMLFileStore(dir string) *FileStore {
+	return &FileStore{Dir: dir, Codec: yamlCodec{}}
+}
+
+func (s *FileStore) dir() string {
+	if s.Dir == "" {
+		return "fixtures/vcr"
+	}
+	return s.Dir
+}
+
+func (s *FileStore) codec() codec {
+	if s.Codec == nil {
+		return jsonCodec{}
+	}
+	return s.Codec
+}
+
+func (s *FileStore) filename(name string) string {
+	ext := s.codec().Ext()
+	if s.Gzip {
+		ext += ".gz"
+	}
+	return filepath.Join(s.dir(), name+"."+ext)
+}
+
+func (s *FileStore) Exists(name string) bool {
+	_, err := os.Stat(s.filename(name))
+	return err == nil
+}
+
+func (s *FileStore) Load(name string) ([]Episode, error) {
+	data, err := ioutil.ReadFile(s.filename(name))
+	if err != nil {
+		return nil, errors.Wrap(err, "httpvcr: cannot read cassette file")
+	}
+
+	if s.Gzip {
+		var out bytes.Buffer
+		if err := gunzipWrite(&out, data); err != nil {
+			return nil, errors.Wrap(err, "httpvcr: gzip read failed")
+		}
+		data = out.Bytes()
+	}
+
+	var episodes []Episode
+	if err := s.codec().Unmarshal(data, &episodes); err != nil {
+		return nil, errors.Wrap(err, "httpvcr: cannot parse cassette file")
+	}
+	return episodes, nil
+}
+
+func (s *FileStore) Save(name string, episodes []Episode) error {
+	data, err := s.codec().Marshal(episodes)
+	if err != nil {
+		return errors.Wrap(err, "httpvcr: cannot encode cassette")
+	}
+
+	if s.Gzip {
+		var out bytes.Buffer
+		if err := gzipWrite(&out, data); err != nil {
+			return errors.Wrap(err, "httpvcr: gzip write failed")
+		}
+		data = out.Bytes()
+	}
+
+	if err := os.MkdirAll(s.dir(), 0755); err != nil {
+		return errors.Wrap(err, "httpvcr: cannot create cassette directory")
+	}
+
+	if err := ioutil.WriteFile(s.filename(name), data, 0644); err != nil {
+		return errors.Wrap(err, "httpvcr: cannot write cassette file")
+	}
+	return nil
+}
+
+// MemoryStore keeps cassettes in memory for the lifetime of the process.
+// Useful for tests that want VCR's recording/replay behaviour without
+// touching fixture files on disk.
+type MemoryStore struct {
+	mu        sync.Mutex
+	cassettes map[string][]Episode
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{cassettes: make(map[string][]Episode)}
+}
+
+func (s *MemoryStore) Exists(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.cassettes[name]
+	return ok
+}
+
+func (s *MemoryStore) Load(name string) ([]Episode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	episodes, ok := s.cassettes[name]
+	if !ok {
+		return nil, errors.Errorf("httpvcr: no cassette named %q in memory store", name)
+	}
+	return episodes, nil
+}
+
+func (s *MemoryStore) Save(name string, episodes []Episode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cassettes[name] = episodes
+	return nil
+}
@@ -0,0 +1,27 @@
+package httpvcr
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+)
+
+// ModifyHTTPRequestBody rewrites request's body in place by passing its
+// current content through fn, updating ContentLength to match. It is a
+// no-op when request has no body, matching http.NewRequest's nil-body
+// convention.
+func ModifyHTTPRequestBody(request *http.Request, fn func(body string) string) {
+	if request.Body == nil {
+		return
+	}
+
+	body, err := ioutil.ReadAll(request.Body)
+	request.Body.Close()
+	if err != nil {
+		return
+	}
+
+	newBody := fn(string(body))
+	request.Body = ioutil.NopCloser(bytes.NewBufferString(newBody))
+	request.ContentLength = int64(len(newBody))
+}